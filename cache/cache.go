@@ -0,0 +1,92 @@
+// Package cache persists analyzeRepository's per-line state across runs,
+// keyed by the commit it was last computed against. Without it, a CI job
+// or nightly dashboard re-walks a repository's entire history on every
+// invocation; with it, only commits added since the last run need to be
+// replayed.
+package cache
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Version is bumped whenever Cache's on-disk schema changes incompatibly.
+// A cache written under a different Version is treated as absent rather
+// than misinterpreted.
+const Version = 1
+
+// Line is a CodeLine's serialized form. It duplicates main.CodeLine's
+// fields rather than importing that type, both to keep this package
+// independent of main and because LineKey's plumbing.Hash doesn't survive
+// a round trip through a JSON map key.
+type Line struct {
+	IntroCommit string
+	File        string
+	LineNum     int
+	Content     string
+	CreatedAt   time.Time
+	DeletedAt   *time.Time
+	CommitHash  string
+	AuthorEmail string
+	LastSeen    time.Time
+}
+
+// Cache is the on-disk state: the HEAD it was computed against, the
+// analysis parameters it was computed under, and every line known as of
+// that HEAD.
+type Cache struct {
+	Version  int
+	HeadHash string
+	Params   string // Fingerprint of the flags that shaped Lines; see main's cacheFingerprint.
+	Lines    []Line
+}
+
+// Load reads and gzip-decompresses a Cache from path. A missing file is
+// not an error: it returns (nil, nil), which callers should treat the
+// same as "no cache yet". A cache written under a different schema
+// Version is likewise returned as (nil, nil) rather than an error, since
+// the caller's only recourse is a full scan either way.
+func Load(path string) (*Cache, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var c Cache
+	if err := json.NewDecoder(gz).Decode(&c); err != nil {
+		return nil, err
+	}
+	if c.Version != Version {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// Save gzip-compresses and writes c to path, overwriting any existing
+// file.
+func (c *Cache) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	if err := json.NewEncoder(gz).Encode(c); err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,73 @@
+// Package cohort slices the line population analyzeRepository tracks into
+// sub-populations — by introducing author, top-level directory, file
+// extension, and creation year — so a single repo-wide half-life doesn't
+// hide that, say, config code outlives feature code by years.
+package cohort
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Dimension is a cohort axis a line can be sliced by.
+type Dimension string
+
+const (
+	Author Dimension = "author"
+	Dir    Dimension = "dir"
+	Ext    Dimension = "ext"
+	Year   Dimension = "year"
+)
+
+// ParseDimensions turns a comma-separated --cohort flag value into its
+// constituent Dimensions, silently ignoring anything unrecognized.
+func ParseDimensions(spec string) []Dimension {
+	if spec == "" {
+		return nil
+	}
+	var dims []Dimension
+	for _, part := range strings.Split(spec, ",") {
+		switch Dimension(strings.TrimSpace(part)) {
+		case Author:
+			dims = append(dims, Author)
+		case Dir:
+			dims = append(dims, Dir)
+		case Ext:
+			dims = append(dims, Ext)
+		case Year:
+			dims = append(dims, Year)
+		}
+	}
+	return dims
+}
+
+// TopDir returns path's top-level directory ("cmd/foo.go" -> "cmd"), or
+// "." for files at the repository root.
+func TopDir(path string) string {
+	path = filepath.ToSlash(path)
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return path[:i]
+	}
+	return "."
+}
+
+// ExtOf returns a file's extension, or "(none)" for extensionless files.
+func ExtOf(path string) string {
+	ext := filepath.Ext(path)
+	if ext == "" {
+		return "(none)"
+	}
+	return ext
+}
+
+// YearOf formats a year as a cohort value.
+func YearOf(year int) string {
+	return strconv.Itoa(year)
+}
+
+// Label formats a dimension/value pair as a cohort key, e.g. "dir:cmd" or
+// "author:jane@example.com".
+func Label(dim Dimension, value string) string {
+	return string(dim) + ":" + value
+}
@@ -0,0 +1,82 @@
+package cohort
+
+import "testing"
+
+func TestTopDir(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"cmd/foo.go", "cmd"},
+		{"cmd/sub/foo.go", "cmd"},
+		{"README.md", "."},
+	}
+	for _, c := range cases {
+		if got := TopDir(c.path); got != c.want {
+			t.Errorf("TopDir(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
+
+func TestExtOf(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"main.go", ".go"},
+		{"cmd/foo.TXT", ".TXT"},
+		{"Makefile", "(none)"},
+	}
+	for _, c := range cases {
+		if got := ExtOf(c.path); got != c.want {
+			t.Errorf("ExtOf(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
+
+func TestYearOf(t *testing.T) {
+	if got := YearOf(2021); got != "2021" {
+		t.Errorf("YearOf(2021) = %q, want 2021", got)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	if got := Label(Dir, "cmd"); got != "dir:cmd" {
+		t.Errorf("Label(Dir, cmd) = %q, want dir:cmd", got)
+	}
+	if got := Label(Author, "jane@example.com"); got != "author:jane@example.com" {
+		t.Errorf("Label(Author, ...) = %q, want author:jane@example.com", got)
+	}
+}
+
+func TestParseDimensions(t *testing.T) {
+	got := ParseDimensions("author, dir,ext,year")
+	want := []Dimension{Author, Dir, Ext, Year}
+	if len(got) != len(want) {
+		t.Fatalf("ParseDimensions = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ParseDimensions()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseDimensionsIgnoresUnrecognized(t *testing.T) {
+	got := ParseDimensions("dir,bogus,year")
+	want := []Dimension{Dir, Year}
+	if len(got) != len(want) {
+		t.Fatalf("ParseDimensions = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ParseDimensions()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseDimensionsEmpty(t *testing.T) {
+	if got := ParseDimensions(""); got != nil {
+		t.Errorf("ParseDimensions(\"\") = %v, want nil", got)
+	}
+}
@@ -0,0 +1,72 @@
+package cohort
+
+import (
+	"bufio"
+	"strings"
+)
+
+// Mailmap canonicalizes author identities per a repository's .mailmap file
+// (see git-mailmap(5)), so "Jane Doe <jane@work.com>" and
+// "jdoe <jane@personal.com>" collapse into one author cohort instead of
+// two.
+type Mailmap struct {
+	byEmail map[string]string // commit email (lowercased) -> canonical email
+}
+
+// ParseMailmap parses a .mailmap file's content. Only the proper-email and
+// commit-email fields are used; the proper-name and commit-name fields
+// that git's own mailmap format also supports are ignored, since cohorts
+// are keyed by email. Lines that don't parse are skipped rather than
+// erroring — a best-effort mapping is still better than none.
+func ParseMailmap(content string) *Mailmap {
+	m := &Mailmap{byEmail: make(map[string]string)}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		emails := extractEmails(line)
+		if len(emails) < 2 {
+			continue
+		}
+		proper := emails[0]
+		for _, commit := range emails[1:] {
+			m.byEmail[strings.ToLower(commit)] = proper
+		}
+	}
+	return m
+}
+
+// Canonical returns email's canonical form per the mailmap, or email
+// itself (lowercased) if it has no mapping. A nil Mailmap (no .mailmap
+// file present) just lowercases.
+func (m *Mailmap) Canonical(email string) string {
+	email = strings.ToLower(email)
+	if m == nil {
+		return email
+	}
+	if proper, ok := m.byEmail[email]; ok {
+		return proper
+	}
+	return email
+}
+
+// extractEmails pulls every <...>-bracketed token out of a mailmap line,
+// in order.
+func extractEmails(line string) []string {
+	var emails []string
+	for {
+		start := strings.IndexByte(line, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(line[start:], '>')
+		if end < 0 {
+			break
+		}
+		emails = append(emails, line[start+1:start+end])
+		line = line[start+end+1:]
+	}
+	return emails
+}
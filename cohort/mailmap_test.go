@@ -0,0 +1,55 @@
+package cohort
+
+import "testing"
+
+func TestParseMailmapCanonicalizesCommitEmail(t *testing.T) {
+	content := "Jane Doe <jane@work.com> <jane@personal.com>\n"
+	m := ParseMailmap(content)
+	if got := m.Canonical("jane@personal.com"); got != "jane@work.com" {
+		t.Errorf("Canonical(jane@personal.com) = %q, want jane@work.com", got)
+	}
+	if got := m.Canonical("JANE@PERSONAL.COM"); got != "jane@work.com" {
+		t.Errorf("Canonical is not case-insensitive: got %q", got)
+	}
+}
+
+func TestParseMailmapUnmappedEmailLowercased(t *testing.T) {
+	m := ParseMailmap("Jane Doe <jane@work.com> <jane@personal.com>\n")
+	if got := m.Canonical("Bob@Example.com"); got != "bob@example.com" {
+		t.Errorf("Canonical(unmapped) = %q, want bob@example.com", got)
+	}
+}
+
+func TestParseMailmapSkipsCommentsAndBlankLines(t *testing.T) {
+	content := "# comment\n\nJane Doe <jane@work.com> <jane@personal.com>\n"
+	m := ParseMailmap(content)
+	if got := m.Canonical("jane@personal.com"); got != "jane@work.com" {
+		t.Errorf("Canonical(jane@personal.com) = %q, want jane@work.com", got)
+	}
+}
+
+func TestParseMailmapSkipsLinesWithoutTwoEmails(t *testing.T) {
+	content := "Jane Doe <jane@work.com>\n"
+	m := ParseMailmap(content)
+	if got := m.Canonical("jane@work.com"); got != "jane@work.com" {
+		t.Errorf("Canonical(jane@work.com) = %q, want jane@work.com unchanged", got)
+	}
+}
+
+func TestParseMailmapMultipleCommitEmailsForOneProper(t *testing.T) {
+	content := "Jane Doe <jane@work.com> <jane@personal.com> <jdoe@old.com>\n"
+	m := ParseMailmap(content)
+	if got := m.Canonical("jane@personal.com"); got != "jane@work.com" {
+		t.Errorf("Canonical(jane@personal.com) = %q, want jane@work.com", got)
+	}
+	if got := m.Canonical("jdoe@old.com"); got != "jane@work.com" {
+		t.Errorf("Canonical(jdoe@old.com) = %q, want jane@work.com", got)
+	}
+}
+
+func TestNilMailmapLowercasesOnly(t *testing.T) {
+	var m *Mailmap
+	if got := m.Canonical("Jane@Work.com"); got != "jane@work.com" {
+		t.Errorf("Canonical on nil Mailmap = %q, want jane@work.com", got)
+	}
+}
@@ -0,0 +1,154 @@
+// Package commitwalk provides fast ancestry iteration over a repository's
+// history. When a commit-graph file is present it discovers ancestry and
+// generation order directly from that file instead of inflating and
+// decompressing each commit object to read its parents; each ancestor is
+// then loaded once as a full commit object to be returned. When the file is
+// absent it falls back to the ordinary object-database walk.
+package commitwalk
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	commitgraphfile "github.com/go-git/go-git/v5/plumbing/format/commitgraph/v2"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+)
+
+// commitGraphPath is where git writes the commit-graph file relative to a
+// repository's .git directory.
+const commitGraphPath = "objects/info/commit-graph"
+
+// Walker resolves ancestor lists for a repository, preferring its
+// commit-graph file when one is present.
+type Walker struct {
+	repo  *git.Repository
+	index commitgraphfile.Index // nil if no commit-graph file was found
+}
+
+// New opens a Walker for the repository rooted at repoPath, looking for the
+// commit-graph file under filepath.Join(repoPath, ".git").
+// The commit-graph file, if found, stays open for the Walker's lifetime —
+// object/commitgraph.NewGraphCommitNodeIndex reads from it lazily on every
+// Ancestors call rather than loading it eagerly — so callers should
+// Close the Walker once done with it.
+func New(repoPath string, repo *git.Repository) (*Walker, error) {
+	w := &Walker{repo: repo}
+
+	f, err := os.Open(filepath.Join(repoPath, ".git", commitGraphPath))
+	if err != nil {
+		// No commit-graph file: fall back to the object-database walk.
+		return w, nil
+	}
+
+	index, err := commitgraphfile.OpenFileIndex(f)
+	if err != nil {
+		// Malformed or unreadable commit-graph: fall back rather than fail.
+		f.Close()
+		return w, nil
+	}
+	w.index = index
+	return w, nil
+}
+
+// Close releases the commit-graph file, if one was opened. It is safe to
+// call on a Walker with no commit-graph file.
+func (w *Walker) Close() error {
+	if w.index == nil {
+		return nil
+	}
+	return w.index.Close()
+}
+
+// HasCommitGraph reports whether a usable commit-graph file was found.
+func (w *Walker) HasCommitGraph() bool {
+	return w.index != nil
+}
+
+// Ancestors returns, in generation-number order (oldest ancestors first),
+// every commit reachable from head. With a commit-graph file the traversal
+// costs a handful of index lookups per commit, plus one object load per
+// commit for the returned *object.Commit; without one it falls back to
+// repo.Log, which walks and decompresses the full object graph.
+func (w *Walker) Ancestors(head plumbing.Hash) ([]*object.Commit, error) {
+	if w.index == nil {
+		return w.ancestorsFromLog(head)
+	}
+	return w.ancestorsFromGraph(head)
+}
+
+func (w *Walker) ancestorsFromLog(head plumbing.Hash) ([]*object.Commit, error) {
+	commitIter, err := w.repo.Log(&git.LogOptions{From: head})
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make([]*object.Commit, 0)
+	err = commitIter.ForEach(func(c *object.Commit) error {
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+
+	return commits, nil
+}
+
+// ancestorsFromGraph walks head's ancestry using the commit-graph index,
+// then sorts the result by Generation() so callers see the same
+// oldest-first order ancestorsFromLog produces. The traversal itself uses
+// an explicit stack rather than recursion: a recursive visit keyed to
+// chain length would overflow on exactly the deep, mostly-linear histories
+// (Linux, Kubernetes) this fast path targets.
+func (w *Walker) ancestorsFromGraph(head plumbing.Hash) ([]*object.Commit, error) {
+	nodeIndex := commitgraph.NewGraphCommitNodeIndex(w.index, w.repo.Storer)
+	root, err := nodeIndex.Get(head)
+	if err != nil {
+		return nil, err
+	}
+
+	visited := make(map[plumbing.Hash]bool)
+	var nodes []commitgraph.CommitNode
+	stack := []commitgraph.CommitNode{root}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[n.ID()] {
+			continue
+		}
+		visited[n.ID()] = true
+		nodes = append(nodes, n)
+
+		for i := n.NumParents() - 1; i >= 0; i-- {
+			parent, err := n.ParentNode(i)
+			if err != nil {
+				return nil, err
+			}
+			if !visited[parent.ID()] {
+				stack = append(stack, parent)
+			}
+		}
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Generation() < nodes[j].Generation()
+	})
+
+	commits := make([]*object.Commit, 0, len(nodes))
+	for _, n := range nodes {
+		c, err := w.repo.CommitObject(n.ID())
+		if err != nil {
+			continue
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
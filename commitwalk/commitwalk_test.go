@@ -0,0 +1,128 @@
+package commitwalk
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// runGit runs git with args in dir, failing the test on error.
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+// initRepoWithCommitGraph creates a tmp repository with 3 linear commits and
+// writes a commit-graph file for it, returning the repo root and the
+// commits' hashes oldest-first.
+func initRepoWithCommitGraph(t *testing.T) (string, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+
+	var hashes []string
+	for i := 0; i < 3; i++ {
+		name := filepath.Join(dir, "file.txt")
+		if err := os.WriteFile(name, []byte{byte('a' + i)}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, dir, "add", "file.txt")
+		runGit(t, dir, "commit", "-q", "-m", string(rune('0'+i)))
+
+		out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, string(out[:len(out)-1]))
+	}
+
+	runGit(t, dir, "commit-graph", "write", "--reachable")
+	return dir, hashes
+}
+
+func TestAncestorsUsesCommitGraphWhenPresent(t *testing.T) {
+	dir, hashes := initRepoWithCommitGraph(t)
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("PlainOpen: %v", err)
+	}
+
+	w, err := New(dir, repo)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer w.Close()
+
+	if !w.HasCommitGraph() {
+		t.Fatal("HasCommitGraph() = false, want true after `git commit-graph write`")
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatalf("Head: %v", err)
+	}
+
+	commits, err := w.Ancestors(head.Hash())
+	if err != nil {
+		t.Fatalf("Ancestors: %v", err)
+	}
+
+	if len(commits) != len(hashes) {
+		t.Fatalf("got %d commits, want %d", len(commits), len(hashes))
+	}
+	for i, c := range commits {
+		if got := c.Hash.String(); got != hashes[i] {
+			t.Errorf("commits[%d] = %s, want %s (oldest-first order)", i, got, hashes[i])
+		}
+	}
+}
+
+func TestAncestorsFallsBackWithoutCommitGraph(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", "file.txt")
+	runGit(t, dir, "commit", "-q", "-m", "only commit")
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatalf("PlainOpen: %v", err)
+	}
+
+	w, err := New(dir, repo)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer w.Close()
+
+	if w.HasCommitGraph() {
+		t.Fatal("HasCommitGraph() = true, want false (no commit-graph file written)")
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatalf("Head: %v", err)
+	}
+
+	commits, err := w.Ancestors(head.Hash())
+	if err != nil {
+		t.Fatalf("Ancestors: %v", err)
+	}
+	if len(commits) != 1 {
+		t.Fatalf("got %d commits, want 1", len(commits))
+	}
+}
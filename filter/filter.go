@@ -0,0 +1,224 @@
+// Package filter decides which files should count toward a half-life
+// analysis. Left unfiltered, vendored dependencies, generated code, and
+// binary blobs badly skew the numbers: a vendored copy of a dependency
+// looks like it "survives" exactly as long as the vendoring commit is
+// untouched, and generated protobuf churns in lockstep with its .proto
+// source rather than with real edits.
+package filter
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Category explains why a file or blob was excluded.
+type Category string
+
+const (
+	CategoryVendored  Category = "vendored"
+	CategoryGenerated Category = "generated"
+	CategoryBinary    Category = "binary"
+)
+
+// Options controls which categories are opted back into analysis.
+type Options struct {
+	IncludeVendored  bool
+	IncludeGenerated bool
+	IncludeBinary    bool
+}
+
+// attributeNames are the .gitattributes keys a path's match is checked
+// against; anything else a repository sets is irrelevant to filtering.
+var attributeNames = []string{"linguist-vendored", "linguist-generated", "binary"}
+
+// builtinPatterns mirrors github-linguist's default vendor/generated path
+// conventions, used as a fallback for repositories with no .gitattributes
+// entry for a given path.
+var builtinPatterns = []struct {
+	re       *regexp.Regexp
+	category Category
+}{
+	{regexp.MustCompile(`^vendor/`), CategoryVendored},
+	{regexp.MustCompile(`^third_party/`), CategoryVendored},
+	{regexp.MustCompile(`^node_modules/`), CategoryVendored},
+	{regexp.MustCompile(`(^|/)deps/`), CategoryVendored},
+	{regexp.MustCompile(`\.pb\.go$`), CategoryGenerated},
+	{regexp.MustCompile(`_generated\.go$`), CategoryGenerated},
+	{regexp.MustCompile(`\.min\.js$`), CategoryGenerated},
+	{regexp.MustCompile(`\.generated\.\w+$`), CategoryGenerated},
+}
+
+// binarySniffBytes is how much of a blob's head we scan for NUL bytes when
+// neither a .gitattributes entry nor a built-in pattern has classified the
+// path.
+const binarySniffBytes = 8192
+
+// Stats tracks how many files and lines were excluded, broken down by why,
+// so a report can show users what was filtered and why.
+type Stats struct {
+	VendoredFiles, VendoredLines   int
+	GeneratedFiles, GeneratedLines int
+	BinaryFiles, BinaryLines       int
+}
+
+// Filter evaluates paths against .gitattributes plus the built-in fallback
+// patterns. A repository's .gitattributes rules change over its history, so
+// a Filter tracks whichever tree it was last pointed at via New or SetTree
+// rather than freezing rules from a single commit.
+type Filter struct {
+	opts    Options
+	stats   Stats
+	matcher gitattributes.Matcher // nil if the current tree has no .gitattributes
+	counted map[string]bool       // paths already reflected in stats, so repeat Allow calls don't double-count
+}
+
+// New creates a Filter for the given tree. It reads .gitattributes at the
+// tree's root if one exists; a missing or unreadable .gitattributes file is
+// not an error, it just means only the built-in patterns apply.
+func New(tree *object.Tree, opts Options) *Filter {
+	f := &Filter{opts: opts, counted: make(map[string]bool)}
+	f.SetTree(tree)
+	return f
+}
+
+// SetTree re-parses .gitattributes from tree, so a Filter walking a
+// repository's history commit-by-commit reflects the rules in force at
+// each point rather than whatever was true at the tree it was created
+// with. Stats and the dedup-counted set carry over unchanged.
+func (f *Filter) SetTree(tree *object.Tree) {
+	f.matcher = nil
+
+	file, err := tree.File(".gitattributes")
+	if err != nil {
+		return
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return
+	}
+	attrs, err := gitattributes.ReadAttributes(bytes.NewReader([]byte(content)), nil, false)
+	if err != nil {
+		return
+	}
+	f.matcher = gitattributes.NewMatcher(attrs)
+}
+
+// Stats returns the running exclusion counts for this Filter.
+func (f *Filter) Stats() Stats {
+	return f.stats
+}
+
+// Allow reports whether f's file should be included in analysis. lineCount
+// is the number of lines the caller is about to count for f; it is only
+// used to keep Stats accurate and has no bearing on the include decision.
+func (f *Filter) Allow(path string, blob *object.Blob, lineCount int) bool {
+	category, excluded := f.classify(path, blob)
+	if !excluded {
+		return true
+	}
+
+	if !f.counted[path] {
+		f.counted[path] = true
+		switch category {
+		case CategoryVendored:
+			f.stats.VendoredFiles++
+			f.stats.VendoredLines += lineCount
+		case CategoryGenerated:
+			f.stats.GeneratedFiles++
+			f.stats.GeneratedLines += lineCount
+		case CategoryBinary:
+			f.stats.BinaryFiles++
+			f.stats.BinaryLines += lineCount
+		}
+	}
+
+	switch category {
+	case CategoryVendored:
+		return f.opts.IncludeVendored
+	case CategoryGenerated:
+		return f.opts.IncludeGenerated
+	case CategoryBinary:
+		return f.opts.IncludeBinary
+	default:
+		return true
+	}
+}
+
+// classify determines a path's category from .gitattributes first, falling
+// back to the built-in path patterns, and finally a binary content sniff.
+func (f *Filter) classify(path string, blob *object.Blob) (Category, bool) {
+	if attr, ok := f.lookupAttribute(path); ok {
+		return attr, true
+	}
+
+	for _, p := range builtinPatterns {
+		if p.re.MatchString(path) {
+			return p.category, true
+		}
+	}
+
+	if isBinaryBlob(blob) {
+		return CategoryBinary, true
+	}
+
+	return "", false
+}
+
+// lookupAttribute matches path against the current tree's .gitattributes
+// entries, honoring linguist-generated, linguist-vendored, and binary.
+func (f *Filter) lookupAttribute(path string) (Category, bool) {
+	if f.matcher == nil {
+		return "", false
+	}
+
+	matched, ok := f.matcher.Match(splitPath(path), attributeNames)
+	if !ok {
+		return "", false
+	}
+
+	if a, ok := matched["linguist-vendored"]; ok && a.IsSet() {
+		return CategoryVendored, true
+	}
+	if a, ok := matched["linguist-generated"]; ok && a.IsSet() {
+		return CategoryGenerated, true
+	}
+	if a, ok := matched["binary"]; ok && a.IsSet() {
+		return CategoryBinary, true
+	}
+	return "", false
+}
+
+func splitPath(path string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' {
+			parts = append(parts, path[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, path[start:])
+	return parts
+}
+
+// isBinaryBlob scans the first binarySniffBytes of a blob's content for a
+// NUL byte, the same heuristic git itself uses to decide whether to diff a
+// file as text.
+func isBinaryBlob(blob *object.Blob) bool {
+	if blob == nil {
+		return false
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return false
+	}
+	defer reader.Close()
+
+	buf := make([]byte, binarySniffBytes)
+	n, _ := io.ReadFull(reader, buf)
+	return bytes.IndexByte(buf[:n], 0) != -1
+}
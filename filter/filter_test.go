@@ -0,0 +1,144 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// newBlob builds a standalone *object.Blob over content, backed by an
+// in-memory object store, for isBinaryBlob to read without needing a full
+// repository.
+func newBlob(t *testing.T, content string) *object.Blob {
+	t.Helper()
+	storer := memory.NewStorage()
+	obj := storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	blob := &object.Blob{}
+	if err := blob.Decode(obj); err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+// newEmptyTree builds a standalone, attribute-free *object.Tree so New can
+// be pointed at something without needing a full repository: classify's
+// built-in-pattern and binary-sniff paths never consult .gitattributes.
+func newEmptyTree(t *testing.T) *object.Tree {
+	t.Helper()
+	storer := memory.NewStorage()
+	obj := storer.NewEncodedObject()
+	obj.SetType(plumbing.TreeObject)
+	tree, err := object.DecodeTree(storer, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestClassifyBuiltinVendorPattern(t *testing.T) {
+	f := New(newEmptyTree(t), Options{})
+	category, excluded := f.classify("vendor/github.com/pkg/errors/errors.go", nil)
+	if !excluded || category != CategoryVendored {
+		t.Errorf("classify(vendor/...) = (%q, %v), want (%q, true)", category, excluded, CategoryVendored)
+	}
+}
+
+func TestClassifyBuiltinGeneratedPattern(t *testing.T) {
+	f := New(newEmptyTree(t), Options{})
+	category, excluded := f.classify("api.pb.go", nil)
+	if !excluded || category != CategoryGenerated {
+		t.Errorf("classify(api.pb.go) = (%q, %v), want (%q, true)", category, excluded, CategoryGenerated)
+	}
+}
+
+func TestClassifyBinaryBlob(t *testing.T) {
+	f := New(newEmptyTree(t), Options{})
+	blob := newBlob(t, "binary\x00content")
+	category, excluded := f.classify("data.bin", blob)
+	if !excluded || category != CategoryBinary {
+		t.Errorf("classify(data.bin) = (%q, %v), want (%q, true)", category, excluded, CategoryBinary)
+	}
+}
+
+func TestClassifyOrdinarySourceNotExcluded(t *testing.T) {
+	f := New(newEmptyTree(t), Options{})
+	blob := newBlob(t, "package main\n")
+	_, excluded := f.classify("main.go", blob)
+	if excluded {
+		t.Errorf("classify(main.go) excluded, want included")
+	}
+}
+
+func TestAllowRespectsIncludeOptions(t *testing.T) {
+	f := New(newEmptyTree(t), Options{IncludeVendored: true})
+	if !f.Allow("vendor/foo.go", nil, 10) {
+		t.Error("Allow(vendor/foo.go) = false, want true with IncludeVendored")
+	}
+
+	f2 := New(newEmptyTree(t), Options{})
+	if f2.Allow("vendor/foo.go", nil, 10) {
+		t.Error("Allow(vendor/foo.go) = true, want false without IncludeVendored")
+	}
+}
+
+func TestAllowCountsExcludedFileOnce(t *testing.T) {
+	f := New(newEmptyTree(t), Options{})
+	f.Allow("vendor/foo.go", nil, 10)
+	f.Allow("vendor/foo.go", nil, 10)
+	stats := f.Stats()
+	if stats.VendoredFiles != 1 || stats.VendoredLines != 10 {
+		t.Errorf("Stats() = %+v, want 1 file and 10 lines counted once", stats)
+	}
+}
+
+func TestIsBinaryBlobNilBlob(t *testing.T) {
+	if isBinaryBlob(nil) {
+		t.Error("isBinaryBlob(nil) = true, want false")
+	}
+}
+
+func TestIsBinaryBlobDetectsNulByte(t *testing.T) {
+	if !isBinaryBlob(newBlob(t, "abc\x00def")) {
+		t.Error("isBinaryBlob with a NUL byte = false, want true")
+	}
+}
+
+func TestIsBinaryBlobPlainText(t *testing.T) {
+	if isBinaryBlob(newBlob(t, "just text, no NUL bytes here")) {
+		t.Error("isBinaryBlob of plain text = true, want false")
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	cases := []struct {
+		path string
+		want []string
+	}{
+		{"foo.go", []string{"foo.go"}},
+		{"cmd/foo/bar.go", []string{"cmd", "foo", "bar.go"}},
+	}
+	for _, c := range cases {
+		got := splitPath(c.path)
+		if len(got) != len(c.want) {
+			t.Fatalf("splitPath(%q) = %v, want %v", c.path, got, c.want)
+		}
+		for i := range c.want {
+			if got[i] != c.want[i] {
+				t.Errorf("splitPath(%q)[%d] = %q, want %q", c.path, i, got[i], c.want[i])
+			}
+		}
+	}
+}
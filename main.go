@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -14,22 +15,44 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/format/diff"
 	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/wbhob/halflife/cache"
+	"github.com/wbhob/halflife/cohort"
+	"github.com/wbhob/halflife/commitwalk"
+	"github.com/wbhob/halflife/filter"
+	"github.com/wbhob/halflife/survival"
 )
 
+// LineKey identifies a line of source across its lifetime. A line is
+// identified by the commit that introduced it, the file it lives in, and
+// its 1-based line number in the blame that recorded it (the tip's for a
+// surviving line, the deleting commit's parent's for a deleted one), which
+// keeps lines apart that happen to share identical content (blank braces,
+// `return nil`, import lines, ...). LineNum is positional: it shifts when
+// lines above it are added or removed.
+type LineKey struct {
+	IntroCommit plumbing.Hash
+	File        string
+	LineNum     int
+}
+
 type CodeLine struct {
-	Content    string
-	File       string
-	CreatedAt  time.Time
-	DeletedAt  *time.Time
-	CommitHash string    // Track which commit created/modified this line
-	LastSeen   time.Time // Last time this line was seen in the codebase
+	Content     string
+	File        string
+	LineNum     int
+	CreatedAt   time.Time
+	DeletedAt   *time.Time
+	CommitHash  string    // Commit that introduced this line
+	AuthorEmail string    // Mailmap-canonicalized email of the introducing commit's author
+	LastSeen    time.Time // Last time this line was seen in the codebase
 }
 
 type Stats struct {
-	HalfLife     float64 // days
+	HalfLife     float64    // days
+	HalfLifeCI   [2]float64 // 95% Greenwood confidence band on survival S(t) at the half-life point, as fractions (not days)
 	TotalLines   int
 	MedianAge    float64 // days
-	SurvivalRate []float64
+	SurvivalRate []survival.Point
 	// Validation metrics
 	OldestLine     string    // Content of the oldest surviving line
 	OldestLineAge  float64   // Age in days of oldest surviving line
@@ -39,6 +62,8 @@ type Stats struct {
 	SurvivingLines int       // Number of lines still alive
 	FirstCommit    time.Time // Timestamp of first commit
 	LastCommit     time.Time // Timestamp of last commit
+	Excluded       filter.Stats
+	CohortStats    map[string]Stats `json:",omitempty"` // Keyed by "dim:value", e.g. "dir:cmd"; nil unless --cohort was set
 }
 
 type ValidationReport struct {
@@ -50,14 +75,14 @@ type ValidationReport struct {
 type TimelineEvent struct {
 	Time         time.Time
 	CommitHash   string
-	Action       string // "create", "delete"
+	Action       string // "create", "delete", "modify"
 	File         string
 	Line         string
 	LinesSoFar   int
 	DeletedSoFar int
 }
 
-func analyzeRepository(repoPath string, filePattern string, validateMode bool) (interface{}, error) {
+func analyzeRepository(repoPath string, filePattern string, validateMode bool, filterOpts filter.Options, renameThreshold, modifyThreshold float64, cohortDims []cohort.Dimension, minCohortSize int, cacheOpts cacheOptions) (interface{}, error) {
 	repo, err := git.PlainOpen(repoPath)
 	if err != nil {
 		return nil, fmt.Errorf("error opening repository: %v", err)
@@ -77,174 +102,1018 @@ func analyzeRepository(repoPath string, filePattern string, validateMode bool) (
 		return nil, fmt.Errorf("could not find main or master branch")
 	}
 
-	codeLines := make(map[string]*CodeLine)
-	var timeline []TimelineEvent
+	tipCommit, err := repo.CommitObject(mainRef.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("error resolving tip commit: %v", err)
+	}
 
-	// Process commits from oldest to newest
-	commits := make([]*object.Commit, 0)
-	commitIter, err := repo.Log(&git.LogOptions{From: mainRef.Hash()})
+	tipTree, err := tipCommit.Tree()
 	if err != nil {
-		return nil, fmt.Errorf("error getting commit iterator: %v", err)
+		return nil, fmt.Errorf("error resolving tip tree: %v", err)
 	}
 
-	err = commitIter.ForEach(func(c *object.Commit) error {
-		commits = append(commits, c)
-		return nil
-	})
+	// Process commits from oldest to newest. commitwalk prefers the
+	// repository's commit-graph file when one is present, which avoids
+	// inflating every commit object just to discover ancestry order.
+	walker, err := commitwalk.New(repoPath, repo)
 	if err != nil {
-		return nil, fmt.Errorf("error iterating commits: %v", err)
+		return nil, fmt.Errorf("error creating commit walker: %v", err)
 	}
+	defer walker.Close()
 
-	// Reverse commits to go from oldest to newest
-	for i := len(commits)/2 - 1; i >= 0; i-- {
-		opp := len(commits) - 1 - i
-		commits[i], commits[opp] = commits[opp], commits[i]
+	commits, err := walker.Ancestors(mainRef.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("error walking commit ancestry: %v", err)
 	}
 
-	// Process each commit
-	for i, commit := range commits {
-		if i == 0 {
-			// For first commit, record all lines as new
-			tree, err := commit.Tree()
-			if err != nil {
-				continue
-			}
+	fingerprint := cacheFingerprint(filePattern, filterOpts, renameThreshold, modifyThreshold)
 
-			err = tree.Files().ForEach(func(f *object.File) error {
-				if !matchesPattern(f.Name, filePattern) {
-					return nil
-				}
+	var priorCache *cache.Cache
+	if cacheOpts.path != "" {
+		priorCache, err = cache.Load(cacheOpts.path)
+		if err != nil {
+			log.Printf("Warning: ignoring unreadable cache at %s: %v", cacheOpts.path, err)
+			priorCache = nil
+		}
+	}
 
-				content, err := f.Contents()
-				if err != nil {
-					return nil
+	walkParams := analysisParams{
+		repo:            repo,
+		tipCommit:       tipCommit,
+		tipTree:         tipTree,
+		commits:         commits,
+		filePattern:     filePattern,
+		filterOpts:      filterOpts,
+		renameThreshold: renameThreshold,
+		modifyThreshold: modifyThreshold,
+		validateMode:    validateMode,
+	}
+
+	var codeLines map[LineKey]*CodeLine
+	var timeline []TimelineEvent
+	var attrFilter *filter.Filter
+	replayed := false // whether the returned codeLines came from a cache replay rather than a full scan
+
+	if cacheOpts.verify && priorCache != nil {
+		replayFrom, seed, baseTree := resolveReplayStart(repo, commits, tipCommit, priorCache, fingerprint)
+		incLines, _, _ := runAnalysis(walkParams, replayFrom, seed, baseTree)
+		codeLines, timeline, attrFilter = runAnalysis(walkParams, 0, nil, nil)
+		if n := countMismatches(incLines, codeLines); n > 0 {
+			log.Printf("cache-verify: incremental result differs from a full-history scan in %d line(s)", n)
+		} else {
+			log.Printf("cache-verify: incremental result matches a full-history scan")
+		}
+	} else {
+		replayFrom, seed, baseTree := 0, map[LineKey]*CodeLine(nil), (*object.Tree)(nil)
+		if priorCache != nil {
+			replayFrom, seed, baseTree = resolveReplayStart(repo, commits, tipCommit, priorCache, fingerprint)
+		}
+		replayed = seed != nil
+		codeLines, timeline, attrFilter = runAnalysis(walkParams, replayFrom, seed, baseTree)
+	}
+
+	if validateMode && replayed {
+		log.Printf("--validate with --cache only reflects commits replayed since the cache was written; Timeline omits earlier create/delete/modify events")
+	}
+
+	if cacheOpts.path != "" {
+		newCache := &cache.Cache{
+			Version:  cache.Version,
+			HeadHash: tipCommit.Hash.String(),
+			Params:   fingerprint,
+			Lines:    linesToCache(codeLines),
+		}
+		if err := newCache.Save(cacheOpts.path); err != nil {
+			log.Printf("Warning: failed to save cache to %s: %v", cacheOpts.path, err)
+		}
+	}
+
+	firstCommit, lastCommit := commits[0].Author.When, commits[len(commits)-1].Author.When
+
+	lineValues := make([]*CodeLine, 0, len(codeLines))
+	for _, line := range codeLines {
+		lineValues = append(lineValues, line)
+	}
+
+	stats, oldestLine, newestLine, ok := computeStats(lineValues, firstCommit, lastCommit, attrFilter.Stats())
+	if !ok {
+		return nil, fmt.Errorf("no valid lifetimes found")
+	}
+
+	if len(cohortDims) > 0 {
+		stats.CohortStats = computeCohortStats(codeLines, cohortDims, minCohortSize, firstCommit, lastCommit)
+	}
+
+	if !validateMode {
+		return stats, nil
+	}
+
+	// For validation mode, collect interesting samples
+	samples := make(map[string]*CodeLine)
+	// Add oldest and newest lines
+	samples["oldest"] = oldestLine
+	samples["newest"] = newestLine
+
+	// Add some random surviving lines
+	survivingSlice := make([]*CodeLine, 0)
+	for _, line := range codeLines {
+		if line.DeletedAt == nil {
+			survivingSlice = append(survivingSlice, line)
+		}
+	}
+	if len(survivingSlice) > 5 {
+		for i := 0; i < 5; i++ {
+			idx := (i * len(survivingSlice)) / 5
+			samples[fmt.Sprintf("sample_%d", i)] = survivingSlice[idx]
+		}
+	}
+
+	return ValidationReport{
+		Stats:    stats,
+		Samples:  samples,
+		Timeline: timeline,
+	}, nil
+}
+
+// cacheOptions bundles --cache and --cache-verify, analyzeRepository's
+// incremental-analysis controls.
+type cacheOptions struct {
+	path   string // Empty means caching is disabled.
+	verify bool
+}
+
+// analysisParams bundles the repository context a runAnalysis call needs,
+// shared between a full scan and a cache-driven replay.
+type analysisParams struct {
+	repo            *git.Repository
+	tipCommit       *object.Commit
+	tipTree         *object.Tree
+	commits         []*object.Commit
+	filePattern     string
+	filterOpts      filter.Options
+	renameThreshold float64
+	modifyThreshold float64
+	validateMode    bool
+}
+
+// runAnalysis builds codeLines by blaming p's tip tree and walking
+// p.commits for deletions, the same two-pass algorithm analyzeRepository
+// has always run. seed, if non-nil, pre-populates codeLines instead of
+// starting empty, and replayFrom is the index of the first commit in
+// p.commits not yet reflected in seed — letting a cached prior run skip
+// re-diffing every commit it already accounted for. replayFrom is
+// clamped to at least 1, since commits[0] has no parent to diff against.
+// baseTree, if non-nil, is the tree seed was blamed against: files that
+// haven't changed between baseTree and p.tipTree are known to already
+// have correct entries in seed, so the tip-blame pass below skips
+// re-blaming them entirely rather than just re-deriving the same result.
+// Called twice with different seeds for --cache-verify, so every local
+// cache (blameCache, authorCache, the attribute filter) is built fresh
+// per call rather than shared.
+func runAnalysis(p analysisParams, replayFrom int, seed map[LineKey]*CodeLine, baseTree *object.Tree) (map[LineKey]*CodeLine, []TimelineEvent, *filter.Filter) {
+	codeLines := make(map[LineKey]*CodeLine, len(seed))
+	for k, v := range seed {
+		codeLines[k] = v
+	}
+	var timeline []TimelineEvent
+
+	blameCache := make(map[string]*git.BlameResult)
+	// fileState carries each file's per-line identity forward commit by
+	// commit through the deletion walk below, seeded lazily (one blame per
+	// file, on first touch) via originsFor instead of re-blaming a file's
+	// full history at every commit that happens to touch it.
+	fileState := make(map[string][]lineOrigin)
+	attrFilter := filter.New(p.tipTree, p.filterOpts)
+	mailmap := loadMailmap(p.tipTree)
+	authorCache := make(map[plumbing.Hash]string)
+
+	// If seed was blamed against baseTree, a file that hasn't changed since
+	// then can't have produced any new lines — reuse seed's entries for it
+	// instead of paying for a fresh Blame, which is the actual expensive
+	// part of this pass (a single-commit deletion walk below is cheap by
+	// comparison).
+	var changedSinceBase map[string]bool
+	var seedByFile map[string][]*CodeLine
+	if baseTree != nil {
+		changedSinceBase = make(map[string]bool)
+		if basePatch, err := baseTree.Patch(p.tipTree); err == nil {
+			for _, fp := range basePatch.FilePatches() {
+				from, to := fp.Files()
+				if from != nil {
+					changedSinceBase[from.Path()] = true
+				}
+				if to != nil {
+					changedSinceBase[to.Path()] = true
 				}
+			}
+		}
+		seedByFile = make(map[string][]*CodeLine, len(codeLines))
+		for key, cl := range codeLines {
+			seedByFile[key.File] = append(seedByFile[key.File], cl)
+		}
+	}
 
-				for _, line := range strings.Split(content, "\n") {
-					if strings.TrimSpace(line) == "" {
-						continue
-					}
-					key := fmt.Sprintf("%s:%s", f.Name, line)
-					codeLines[key] = &CodeLine{
-						Content:    line,
-						File:       f.Name,
-						CreatedAt:  commit.Author.When,
-						LastSeen:   commit.Author.When,
-						CommitHash: commit.Hash.String(),
-					}
-					if validateMode {
-						timeline = append(timeline, TimelineEvent{
-							Time:       commit.Author.When,
-							CommitHash: commit.Hash.String(),
-							Action:     "create",
-							File:       f.Name,
-							Line:       line,
-							LinesSoFar: len(codeLines),
-						})
-					}
+	// Seed identity for every surviving line by blaming the tip tree: this
+	// gives the true introducing commit per line instead of assuming the
+	// line was created wherever it first appears in the add/delete walk.
+	// Files unchanged since baseTree reuse seed's entries (see above); every
+	// other matching tip file is blamed afresh, cached run or not. It's the
+	// per-commit deletion walk below that a cache lets us skip most of,
+	// since that one scales with the length of the repo's whole history
+	// rather than the size of its tip.
+	err := p.tipTree.Files().ForEach(func(f *object.File) error {
+		if !matchesPattern(f.Name, p.filePattern) {
+			return nil
+		}
+
+		if changedSinceBase != nil && !changedSinceBase[f.Name] {
+			if lines, ok := seedByFile[f.Name]; ok {
+				for _, cl := range lines {
+					cl.LastSeen = p.tipCommit.Author.When
 				}
 				return nil
-			})
-			if err != nil {
-				log.Printf("Warning: error processing initial commit: %v", err)
 			}
-			continue
 		}
 
-		parent := commits[i-1]
+		content, err := f.Contents()
+		if err != nil {
+			return nil
+		}
+		if !attrFilter.Allow(f.Name, &f.Blob, countNonBlankLines(content)) {
+			return nil
+		}
+
+		blame, err := blameFile(p.repo, p.tipCommit, f.Name, blameCache)
+		if err != nil {
+			return nil
+		}
+
+		for i, blameLine := range blame.Lines {
+			if strings.TrimSpace(blameLine.Text) == "" {
+				continue
+			}
+			key := LineKey{IntroCommit: blameLine.Hash, File: f.Name, LineNum: i + 1}
+			if existing, ok := codeLines[key]; ok {
+				// Already known, possibly with CreatedAt/CommitHash
+				// corrected by an earlier modify-pairing pass that this
+				// run never replays because it happened before
+				// replayFrom. A fresh blame of this exact key can only
+				// ever reproduce the naive, uncorrected identity, so
+				// keep the existing entry and just mark it alive.
+				existing.LastSeen = p.tipCommit.Author.When
+				continue
+			}
+			codeLines[key] = &CodeLine{
+				Content:     blameLine.Text,
+				File:        f.Name,
+				LineNum:     i + 1,
+				CreatedAt:   blameLine.Date,
+				LastSeen:    p.tipCommit.Author.When,
+				CommitHash:  blameLine.Hash.String(),
+				AuthorEmail: authorEmailFor(p.repo, blameLine.Hash, authorCache, mailmap),
+			}
+			if p.validateMode {
+				timeline = append(timeline, TimelineEvent{
+					Time:       blameLine.Date,
+					CommitHash: blameLine.Hash.String(),
+					Action:     "create",
+					File:       f.Name,
+					Line:       blameLine.Text,
+					LinesSoFar: len(codeLines),
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: error blaming tip tree: %v", err)
+	}
+
+	// originIndex lets the deletion walk's modify-pairing below find a
+	// surviving line by (file, introducing commit) instead of by a
+	// tip-relative line number, which drifts whenever unrelated edits
+	// elsewhere in the file shift lines around between the modifying
+	// commit and tip. Built once, consumed destructively as matches are
+	// claimed so the same survivor is never attributed to two different
+	// historical modifications.
+	originIndex := make(map[string][]*CodeLine, len(codeLines))
+	for key, cl := range codeLines {
+		k := originKey(key.File, key.IntroCommit)
+		originIndex[k] = append(originIndex[k], cl)
+	}
+
+	// Walk history oldest -> newest to find when lines were deleted. For
+	// each deletion chunk, look up the parent's per-line identities
+	// (fileState, seeded by one blame of the file on first touch) to
+	// recover the (introCommit, file, lineNum) identity of the deleted
+	// line, since the delete chunk itself only carries content and a
+	// position in the parent's version of the file. Within a commit, a
+	// deleted line is first checked against that commit's added lines (same
+	// path, or a differently-named path that looks like a rename) for a
+	// near-duplicate match: if one is found within modifyThreshold, it is a
+	// modification that preserves the original line's identity rather than
+	// a true delete+create.
+	start := replayFrom
+	if start < 1 {
+		start = 1
+	}
+	for i := start; i < len(p.commits); i++ {
+		commit := p.commits[i]
+		parent := p.commits[i-1]
+
+		// .gitattributes itself can change over history, so re-resolve it
+		// against the parent's tree (the tree the deleted/renamed lines
+		// actually lived in) rather than keeping the tip's rules pinned for
+		// the whole walk.
+		if parentTree, err := parent.Tree(); err == nil {
+			attrFilter.SetTree(parentTree)
+		}
+
 		patch, err := commit.Patch(parent)
 		if err != nil {
 			continue
 		}
 
+		var wholeDeletes, wholeAdds []diff.FilePatch
+
 		for _, filePatch := range patch.FilePatches() {
 			from, to := filePatch.Files()
 			if to == nil && from == nil {
 				continue
 			}
+			if to == nil {
+				wholeDeletes = append(wholeDeletes, filePatch)
+				continue
+			}
+			if from == nil {
+				wholeAdds = append(wholeAdds, filePatch)
+				continue
+			}
+
+			path := to.Path()
+			if !matchesPattern(path, p.filePattern) || !attrFilter.Allow(path, nil, 0) {
+				continue
+			}
 
-			var path string
-			if to != nil {
-				path = to.Path()
-			} else {
-				path = from.Path()
+			deletions, additions := collectChunkLines(filePatch)
+			origins, originsErr := originsFor(fileState, p.repo, parent, path, blameCache)
+			matchedAddOrigin := applyLinePairing(p.repo, codeLines, originIndex, &timeline, p.validateMode, commit, path, path, deletions, additions, origins, originsErr, p.modifyThreshold, authorCache, mailmap)
+			if originsErr == nil {
+				fileState[path] = advanceFileState(origins, filePatch, commit, matchedAddOrigin)
 			}
+		}
+
+		// Pair whole-file deletes with whole-file adds that share enough
+		// content to plausibly be the same file moved or renamed, the same
+		// heuristic behind git's -M/-C.
+		renamePartner := make(map[int]int) // wholeDeletes index -> wholeAdds index
+		pairedAdd := make(map[int]bool)
+		for di, delPatch := range wholeDeletes {
+			from, _ := delPatch.Files()
+			oldContent, err := fileContentAt(parent, from.Path())
+			if err != nil {
+				continue
+			}
+
+			bestAdd, bestScore := -1, p.renameThreshold
+			for ai, addPatch := range wholeAdds {
+				if pairedAdd[ai] {
+					continue
+				}
+				_, to := addPatch.Files()
+				newContent, err := fileContentAt(commit, to.Path())
+				if err != nil {
+					continue
+				}
+				if score := shingleSimilarity(oldContent, newContent, 5); score >= bestScore {
+					bestScore, bestAdd = score, ai
+				}
+			}
+			if bestAdd >= 0 {
+				renamePartner[di] = bestAdd
+				pairedAdd[bestAdd] = true
+			}
+		}
+
+		// A whole-file add with no rename partner is a brand new file:
+		// seed its state directly from the patch rather than waiting for
+		// its first later touch to trigger a fallback blame.
+		for ai, addPatch := range wholeAdds {
+			if pairedAdd[ai] {
+				continue
+			}
+			_, to := addPatch.Files()
+			fileState[to.Path()] = newFileState(addPatch, commit)
+		}
 
-			if !matchesPattern(path, filePattern) {
+		for di, delPatch := range wholeDeletes {
+			from, _ := delPatch.Files()
+			oldPath := from.Path()
+			if !matchesPattern(oldPath, p.filePattern) || !attrFilter.Allow(oldPath, nil, 0) {
 				continue
 			}
 
-			for _, chunk := range filePatch.Chunks() {
-				switch chunk.Type() {
-				case diff.Add:
-					for _, line := range strings.Split(chunk.Content(), "\n") {
-						if strings.TrimSpace(line) == "" {
-							continue
-						}
-						key := fmt.Sprintf("%s:%s", path, line)
-						if _, exists := codeLines[key]; !exists {
-							codeLines[key] = &CodeLine{
-								Content:    line,
-								File:       path,
-								CreatedAt:  commit.Author.When,
-								LastSeen:   commit.Author.When,
-								CommitHash: commit.Hash.String(),
-							}
-							if validateMode {
-								timeline = append(timeline, TimelineEvent{
-									Time:       commit.Author.When,
-									CommitHash: commit.Hash.String(),
-									Action:     "create",
-									File:       path,
-									Line:       line,
-									LinesSoFar: len(codeLines),
-								})
-							}
-						} else {
-							codeLines[key].LastSeen = commit.Author.When
-						}
-					}
-				case diff.Delete:
-					for _, line := range strings.Split(chunk.Content(), "\n") {
-						if strings.TrimSpace(line) == "" {
-							continue
-						}
-						key := fmt.Sprintf("%s:%s", path, line)
-						if cl, exists := codeLines[key]; exists {
-							deletedAt := commit.Author.When
-							cl.DeletedAt = &deletedAt
-							if validateMode {
-								timeline = append(timeline, TimelineEvent{
-									Time:         commit.Author.When,
-									CommitHash:   commit.Hash.String(),
-									Action:       "delete",
-									File:         path,
-									Line:         line,
-									LinesSoFar:   len(codeLines),
-									DeletedSoFar: countDeletedLines(codeLines),
-								})
-							}
-						}
-					}
+			deletions, _ := collectChunkLines(delPatch)
+			// Whole-file deletes and renames are rare next to in-place
+			// modifications of the same path, so they don't exhibit the
+			// quadratic blowup the incremental fileState above exists to
+			// avoid; blaming oldPath's one remaining appearance here is
+			// cheap enough to leave as-is.
+			blame, blameErr := blameFile(p.repo, parent, oldPath, blameCache)
+			var origins []lineOrigin
+			if blameErr == nil {
+				origins = blameOrigins(blame)
+			}
+			delete(fileState, oldPath)
+
+			if ai, ok := renamePartner[di]; ok {
+				_, to := wholeAdds[ai].Files()
+				newPath := to.Path()
+				_, additions := collectChunkLines(wholeAdds[ai])
+				matchedAddOrigin := applyLinePairing(p.repo, codeLines, originIndex, &timeline, p.validateMode, commit, oldPath, newPath, deletions, additions, origins, blameErr, p.modifyThreshold, authorCache, mailmap)
+				if blameErr == nil {
+					fileState[newPath] = advanceFileState(nil, wholeAdds[ai], commit, matchedAddOrigin)
+				}
+				continue
+			}
+
+			applyLinePairing(p.repo, codeLines, originIndex, &timeline, p.validateMode, commit, oldPath, oldPath, deletions, nil, origins, blameErr, p.modifyThreshold, authorCache, mailmap)
+		}
+	}
+
+	return codeLines, timeline, attrFilter
+}
+
+// resolveReplayStart finds where runAnalysis can resume from after a
+// cached run, using the merge-base of the cached HEAD and the current tip
+// rather than requiring an exact match, so a plain fast-forward onto new
+// commits still hits the fast path. If the cached HEAD can't be resolved
+// in this repository, or the tip has diverged from it (a rebase or
+// force-push), the cache is discarded and a full scan runs instead — the
+// safe fallback, since replaying deletions against the wrong base would
+// silently corrupt DeletedAt for lines that were never actually touched.
+// The returned tree is the cached HEAD's, for skipping re-blame on files
+// unchanged since then; it is nil whenever the cache itself is rejected.
+func resolveReplayStart(repo *git.Repository, commits []*object.Commit, tipCommit *object.Commit, priorCache *cache.Cache, params string) (int, map[LineKey]*CodeLine, *object.Tree) {
+	if priorCache.Params != params {
+		log.Printf("Cache was built with different analysis flags; ignoring cache and running a full scan")
+		return 0, nil, nil
+	}
+
+	cachedHash := plumbing.NewHash(priorCache.HeadHash)
+	cachedCommit, err := repo.CommitObject(cachedHash)
+	if err != nil {
+		log.Printf("Cached HEAD %s not found in repository; ignoring cache and running a full scan", priorCache.HeadHash)
+		return 0, nil, nil
+	}
+
+	bases, err := tipCommit.MergeBase(cachedCommit)
+	if err != nil || len(bases) == 0 || bases[0].Hash != cachedHash {
+		log.Printf("Cached HEAD %s is not an ancestor of the current tip; ignoring cache and running a full scan", priorCache.HeadHash)
+		return 0, nil, nil
+	}
+
+	cachedTree, err := cachedCommit.Tree()
+	if err != nil {
+		log.Printf("Cached HEAD %s has no readable tree; ignoring cache and running a full scan", priorCache.HeadHash)
+		return 0, nil, nil
+	}
+
+	for i, c := range commits {
+		if c.Hash == cachedHash {
+			return i + 1, cacheToLines(priorCache.Lines), cachedTree
+		}
+	}
+	return 0, nil, nil
+}
+
+// cacheFingerprint summarizes the analysis parameters that shape Lines, so
+// a cache built under a different file pattern or rename/modify threshold
+// is never silently replayed as if it still applied: any of these changing
+// the set or identity of lines that filePattern/filterOpts/threshold
+// changes would otherwise reinterpret without a full rescan.
+func cacheFingerprint(filePattern string, filterOpts filter.Options, renameThreshold, modifyThreshold float64) string {
+	return fmt.Sprintf("%s|%v|%g|%g", filePattern, filterOpts, renameThreshold, modifyThreshold)
+}
+
+// countMismatches reports how many lines differ between two codeLines
+// results, comparing only the fields a bad replay could get wrong
+// (CreatedAt, CommitHash, DeletedAt) rather than requiring full struct
+// equality.
+func countMismatches(a, b map[LineKey]*CodeLine) int {
+	mismatches := 0
+	for key, la := range a {
+		lb, ok := b[key]
+		if !ok || !linesEqual(la, lb) {
+			mismatches++
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			mismatches++
+		}
+	}
+	return mismatches
+}
+
+func linesEqual(a, b *CodeLine) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) || a.CommitHash != b.CommitHash {
+		return false
+	}
+	if (a.DeletedAt == nil) != (b.DeletedAt == nil) {
+		return false
+	}
+	return a.DeletedAt == nil || a.DeletedAt.Equal(*b.DeletedAt)
+}
+
+// linesToCache converts codeLines into Cache's serializable form.
+func linesToCache(codeLines map[LineKey]*CodeLine) []cache.Line {
+	lines := make([]cache.Line, 0, len(codeLines))
+	for key, cl := range codeLines {
+		lines = append(lines, cache.Line{
+			IntroCommit: key.IntroCommit.String(),
+			File:        key.File,
+			LineNum:     key.LineNum,
+			Content:     cl.Content,
+			CreatedAt:   cl.CreatedAt,
+			DeletedAt:   cl.DeletedAt,
+			CommitHash:  cl.CommitHash,
+			AuthorEmail: cl.AuthorEmail,
+			LastSeen:    cl.LastSeen,
+		})
+	}
+	return lines
+}
+
+// cacheToLines reconstructs a codeLines map from a cache's serialized
+// lines, the inverse of linesToCache.
+func cacheToLines(lines []cache.Line) map[LineKey]*CodeLine {
+	codeLines := make(map[LineKey]*CodeLine, len(lines))
+	for _, l := range lines {
+		key := LineKey{IntroCommit: plumbing.NewHash(l.IntroCommit), File: l.File, LineNum: l.LineNum}
+		codeLines[key] = &CodeLine{
+			Content:     l.Content,
+			File:        l.File,
+			LineNum:     l.LineNum,
+			CreatedAt:   l.CreatedAt,
+			DeletedAt:   l.DeletedAt,
+			CommitHash:  l.CommitHash,
+			AuthorEmail: l.AuthorEmail,
+			LastSeen:    l.LastSeen,
+		}
+	}
+	return codeLines
+}
+
+// blameFile runs (and caches) go-git's Blame algorithm for path as seen at
+// commit, keyed by "<commit>:<path>" so the same tree is never blamed twice
+// across the tip pass and the per-commit deletion walk.
+func blameFile(repo *git.Repository, commit *object.Commit, path string, blameCache map[string]*git.BlameResult) (*git.BlameResult, error) {
+	key := commit.Hash.String() + ":" + path
+	if blame, ok := blameCache[key]; ok {
+		return blame, nil
+	}
+	blame, err := git.Blame(commit, path)
+	if err != nil {
+		return nil, err
+	}
+	blameCache[key] = blame
+	return blame, nil
+}
+
+// lineOrigin is a line's identity as of some point in history: the commit
+// that introduced it and that commit's author date, the two pieces of a
+// git.BlameResult.Lines entry the deletion walk actually needs.
+type lineOrigin struct {
+	hash plumbing.Hash
+	date time.Time
+}
+
+// blameOrigins converts a full blame into the lineOrigin slice the
+// deletion walk's incremental state carries forward.
+func blameOrigins(blame *git.BlameResult) []lineOrigin {
+	origins := make([]lineOrigin, len(blame.Lines))
+	for i, l := range blame.Lines {
+		origins[i] = lineOrigin{hash: l.Hash, date: l.Date}
+	}
+	return origins
+}
+
+// originsFor returns path's per-line identity as of parent, preferring
+// fileState's already-tracked value (free) and falling back to a single
+// git.Blame only the first time the deletion walk sees this file. Every
+// later commit that touches the same path updates fileState from that
+// commit's diff instead of re-blaming from scratch, which is what made a
+// file edited at almost every commit in its history cost roughly the
+// square of its commit count rather than scaling with it.
+func originsFor(fileState map[string][]lineOrigin, repo *git.Repository, parent *object.Commit, path string, blameCache map[string]*git.BlameResult) ([]lineOrigin, error) {
+	if origins, ok := fileState[path]; ok {
+		return origins, nil
+	}
+	blame, err := blameFile(repo, parent, path, blameCache)
+	if err != nil {
+		return nil, err
+	}
+	origins := blameOrigins(blame)
+	fileState[path] = origins
+	return origins, nil
+}
+
+// newFileState builds the initial per-line identity for a file created by
+// filePatch (a whole-file add), attributing every line to commit without
+// needing a blame at all.
+func newFileState(filePatch diff.FilePatch, commit *object.Commit) []lineOrigin {
+	var state []lineOrigin
+	for _, chunk := range filePatch.Chunks() {
+		if chunk.Type() != diff.Add {
+			continue
+		}
+		for range splitChunkLines(chunk.Content()) {
+			state = append(state, lineOrigin{hash: commit.Hash, date: commit.Author.When})
+		}
+	}
+	return state
+}
+
+// advanceFileState replays filePatch's chunks against oldState (the
+// file's line identities as of parent) to produce its identities as of
+// commit. Lines carried through an Equal chunk keep their old identity.
+// An added line that applyLinePairing matched to a deletion (a modify,
+// not a true delete+create) keeps that deletion's original origin via
+// matchedAddOrigin, so a later deletion of this same, merely reflowed,
+// line still attributes back to the commit that truly introduced it;
+// every other added line is attributed to commit itself.
+func advanceFileState(oldState []lineOrigin, filePatch diff.FilePatch, commit *object.Commit, matchedAddOrigin map[int]lineOrigin) []lineOrigin {
+	var newState []lineOrigin
+	oldIdx, addIdx := 0, 0
+	for _, chunk := range filePatch.Chunks() {
+		lines := splitChunkLines(chunk.Content())
+		switch chunk.Type() {
+		case diff.Equal:
+			for range lines {
+				if oldIdx < len(oldState) {
+					newState = append(newState, oldState[oldIdx])
+				} else {
+					newState = append(newState, lineOrigin{hash: commit.Hash, date: commit.Author.When})
+				}
+				oldIdx++
+			}
+		case diff.Delete:
+			oldIdx += len(lines)
+		case diff.Add:
+			for _, line := range lines {
+				if strings.TrimSpace(line) == "" {
+					newState = append(newState, lineOrigin{hash: commit.Hash, date: commit.Author.When})
+					continue
+				}
+				origin, ok := matchedAddOrigin[addIdx]
+				if !ok {
+					origin = lineOrigin{hash: commit.Hash, date: commit.Author.When}
+				}
+				newState = append(newState, origin)
+				addIdx++
+			}
+		}
+	}
+	return newState
+}
+
+// loadMailmap reads .mailmap from tree's root if one exists. A missing or
+// unreadable .mailmap is not an error; author cohorts just fall back to
+// each commit's author email, lowercased.
+func loadMailmap(tree *object.Tree) *cohort.Mailmap {
+	file, err := tree.File(".mailmap")
+	if err != nil {
+		return nil
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return nil
+	}
+	return cohort.ParseMailmap(content)
+}
+
+// authorEmailFor returns the mailmap-canonicalized author email of the
+// commit identified by hash, caching the lookup since the same introducing
+// commit is revisited by many lines.
+func authorEmailFor(repo *git.Repository, hash plumbing.Hash, authorCache map[plumbing.Hash]string, mailmap *cohort.Mailmap) string {
+	if email, ok := authorCache[hash]; ok {
+		return email
+	}
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return ""
+	}
+	email := mailmap.Canonical(commit.Author.Email)
+	authorCache[hash] = email
+	return email
+}
+
+// countNonBlankLines counts the lines in content that survive the same
+// whitespace-only filter used throughout analyzeRepository.
+func countNonBlankLines(content string) int {
+	count := 0
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// splitChunkLines splits a diff chunk's content into its constituent lines,
+// dropping the trailing empty element strings.Split leaves behind when the
+// chunk ends in a newline.
+func splitChunkLines(content string) []string {
+	lines := strings.Split(content, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineSample is a single line pulled out of a diff chunk, along with its
+// 1-indexed position in the file it belongs to (the parent's version for a
+// deletion, the new version for an addition).
+type lineSample struct {
+	lineNum int
+	content string
+}
+
+// collectChunkLines walks filePatch's chunks once and separates its deleted
+// and added lines, each tagged with its position in the relevant side of
+// the diff.
+func collectChunkLines(filePatch diff.FilePatch) (deletions, additions []lineSample) {
+	lineNum, newLineNum := 0, 0
+	for _, chunk := range filePatch.Chunks() {
+		lines := splitChunkLines(chunk.Content())
+		switch chunk.Type() {
+		case diff.Equal:
+			lineNum += len(lines)
+			newLineNum += len(lines)
+		case diff.Delete:
+			for _, line := range lines {
+				lineNum++
+				if strings.TrimSpace(line) != "" {
+					deletions = append(deletions, lineSample{lineNum: lineNum, content: line})
+				}
+			}
+		case diff.Add:
+			for _, line := range lines {
+				newLineNum++
+				if strings.TrimSpace(line) != "" {
+					additions = append(additions, lineSample{lineNum: newLineNum, content: line})
 				}
 			}
 		}
 	}
+	return deletions, additions
+}
+
+// applyLinePairing resolves a commit's deleted lines against its added
+// lines. A deleted line whose normalized content is at least
+// modifyThreshold similar to an unmatched added line is recorded as a
+// "modify": the surviving line (already present in codeLines from the tip
+// blame pass, found in originIndex by (addPath, commit.Hash) and claimed
+// by matching content rather than a tip-relative line number — unrelated
+// edits elsewhere in the file routinely shift a surviving line's position
+// between this commit and tip, so the line number it was added at here is
+// not a reliable way to find it again) has its CreatedAt/CommitHash
+// corrected back to the deleted line's true origin instead of the commit
+// that just reflowed it. Deleted lines with no match are recorded as
+// ordinary deletions. origins is deletePath's per-line identity as of
+// commit's parent; originsErr being non-nil (that identity couldn't be
+// resolved) skips every deletion rather than guessing. The returned map,
+// keyed by index into additions, carries each matched addition's original
+// origin forward so the caller can fold it into deletePath/addPath's
+// incremental state instead of re-deriving it.
+func applyLinePairing(repo *git.Repository, codeLines map[LineKey]*CodeLine, originIndex map[string][]*CodeLine, timeline *[]TimelineEvent, validateMode bool, commit *object.Commit, deletePath, addPath string, deletions, additions []lineSample, origins []lineOrigin, originsErr error, modifyThreshold float64, authorCache map[plumbing.Hash]string, mailmap *cohort.Mailmap) map[int]lineOrigin {
+	matchedAdd := make(map[int]bool)
+	matchedAddOrigin := make(map[int]lineOrigin)
 
-	// Calculate statistics
+	for _, del := range deletions {
+		if originsErr != nil || del.lineNum-1 >= len(origins) {
+			continue
+		}
+		origin := origins[del.lineNum-1]
+
+		bestIdx, bestScore := -1, modifyThreshold
+		for ai, add := range additions {
+			if matchedAdd[ai] {
+				continue
+			}
+			if score := lineSimilarity(del.content, add.content); score >= bestScore {
+				bestScore, bestIdx = score, ai
+			}
+		}
+
+		if bestIdx >= 0 {
+			matchedAdd[bestIdx] = true
+			matchedAddOrigin[bestIdx] = origin
+			add := additions[bestIdx]
+			if newLine := claimOrigin(originIndex, addPath, commit.Hash, add.content); newLine != nil {
+				newLine.CreatedAt = origin.date
+				newLine.CommitHash = origin.hash.String()
+				newLine.AuthorEmail = authorEmailFor(repo, origin.hash, authorCache, mailmap)
+			}
+			if validateMode {
+				*timeline = append(*timeline, TimelineEvent{
+					Time:         commit.Author.When,
+					CommitHash:   commit.Hash.String(),
+					Action:       "modify",
+					File:         addPath,
+					Line:         add.content,
+					LinesSoFar:   len(codeLines),
+					DeletedSoFar: countDeletedLines(codeLines),
+				})
+			}
+			continue
+		}
+
+		key := LineKey{IntroCommit: origin.hash, File: deletePath, LineNum: del.lineNum}
+		cl, exists := codeLines[key]
+		if !exists {
+			cl = &CodeLine{
+				Content:     del.content,
+				File:        deletePath,
+				LineNum:     del.lineNum,
+				CreatedAt:   origin.date,
+				LastSeen:    origin.date,
+				CommitHash:  origin.hash.String(),
+				AuthorEmail: authorEmailFor(repo, origin.hash, authorCache, mailmap),
+			}
+			codeLines[key] = cl
+		}
+		deletedAt := commit.Author.When
+		cl.DeletedAt = &deletedAt
+		if validateMode {
+			*timeline = append(*timeline, TimelineEvent{
+				Time:         commit.Author.When,
+				CommitHash:   commit.Hash.String(),
+				Action:       "delete",
+				File:         deletePath,
+				Line:         del.content,
+				LinesSoFar:   len(codeLines),
+				DeletedSoFar: countDeletedLines(codeLines),
+			})
+		}
+	}
+
+	return matchedAddOrigin
+}
+
+// originKey identifies a surviving line by the file it lives in and the
+// commit blame attributes it to, for lookups that must stay valid even
+// after unrelated edits elsewhere in the file shift a tip-relative line
+// number around.
+func originKey(file string, introCommit plumbing.Hash) string {
+	return file + "\x00" + introCommit.String()
+}
+
+// claimOrigin finds and removes the first not-yet-claimed entry under
+// (file, introCommit) whose content matches, so a later lookup for the
+// same pair can't reuse it for a different line. A miss (nil) means the
+// line was touched again by some commit after introCommit and before tip
+// — blame would then attribute it to that later commit instead, which is
+// correct: that later commit's own pass through this same walk is
+// responsible for its identity.
+func claimOrigin(index map[string][]*CodeLine, file string, introCommit plumbing.Hash, content string) *CodeLine {
+	k := originKey(file, introCommit)
+	candidates := index[k]
+	for i, cl := range candidates {
+		if cl.Content == content {
+			index[k] = append(candidates[:i], candidates[i+1:]...)
+			return cl
+		}
+	}
+	return nil
+}
+
+// fileContentAt returns the full text content of path as it exists in
+// commit's tree.
+func fileContentAt(commit *object.Commit, path string) (string, error) {
+	f, err := commit.File(path)
+	if err != nil {
+		return "", err
+	}
+	return f.Contents()
+}
+
+// normalizeLine strips whitespace so a reflow (re-indentation, trailing
+// space, wrapped args) doesn't register as a content change.
+func normalizeLine(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if !strings.ContainsRune(" \t\r\n", r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// lineSimilarity scores how similar two lines are after whitespace
+// normalization, as 1 minus the normalized Levenshtein distance.
+func lineSimilarity(a, b string) float64 {
+	na, nb := normalizeLine(a), normalizeLine(b)
+	if na == nb {
+		return 1
+	}
+	maxLen := len(na)
+	if len(nb) > maxLen {
+		maxLen = len(nb)
+	}
+	if maxLen == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(na, nb))/float64(maxLen)
+}
+
+// levenshtein computes the edit distance between two strings.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// shingleSimilarity estimates how much two whole-file contents overlap via
+// Jaccard similarity over k-rune shingles, the same style of fingerprint
+// git's rename detection uses to decide two blobs are "similar enough".
+func shingleSimilarity(a, b string, k int) float64 {
+	sa, sb := shingles(a, k), shingles(b, k)
+	if len(sa) == 0 || len(sb) == 0 {
+		return 0
+	}
+	intersection := 0
+	for s := range sa {
+		if sb[s] {
+			intersection++
+		}
+	}
+	union := len(sa) + len(sb) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
+
+func shingles(s string, k int) map[string]bool {
+	set := make(map[string]bool)
+	runes := []rune(s)
+	if len(runes) < k {
+		if len(runes) > 0 {
+			set[string(runes)] = true
+		}
+		return set
+	}
+	for i := 0; i+k <= len(runes); i++ {
+		set[string(runes[i:i+k])] = true
+	}
+	return set
+}
+
+// computeStats reduces lines to a Kaplan-Meier Stats summary. Surviving
+// lines are right-censored at "now"; deleted lines contribute an observed
+// lifetime. ok is false when lines has no usable lifetime at all (e.g. an
+// empty cohort), in which case stats is the zero value.
+func computeStats(lines []*CodeLine, firstCommit, lastCommit time.Time, excluded filter.Stats) (stats Stats, oldestLine, newestLine *CodeLine, ok bool) {
 	var lifetimes []float64
+	var observations []survival.Observation
 	now := time.Now()
 	totalLines := 0
 	survivingLines := 0
-	var oldestLine, newestLine *CodeLine
 	var oldestAge, newestAge float64
 
-	for _, line := range codeLines {
+	for _, line := range lines {
 		if line.DeletedAt == nil {
 			survivingLines++
 			age := now.Sub(line.CreatedAt).Hours() / 24
 			if age > 0 {
 				lifetimes = append(lifetimes, age)
+				observations = append(observations, survival.Observation{Duration: age, Event: false})
 				if oldestLine == nil || age > oldestAge {
 					oldestLine = line
 					oldestAge = age
@@ -258,94 +1127,87 @@ func analyzeRepository(repoPath string, filePattern string, validateMode bool) (
 			lifetime := line.DeletedAt.Sub(line.CreatedAt).Hours() / 24
 			if lifetime > 0 {
 				lifetimes = append(lifetimes, lifetime)
+				observations = append(observations, survival.Observation{Duration: lifetime, Event: true})
 			}
 		}
 		totalLines++
 	}
 
 	if len(lifetimes) == 0 {
-		return nil, fmt.Errorf("no valid lifetimes found")
+		return Stats{}, nil, nil, false
 	}
 
 	sort.Float64s(lifetimes)
 
-	// Calculate survival rate over time
-	survivalRate := make([]float64, 0)
-	maxAge := lifetimes[len(lifetimes)-1]
-	timePoints := 100 // number of points to sample
-	for i := 0; i < timePoints; i++ {
-		timePoint := (maxAge * float64(i)) / float64(timePoints)
-		survived := 0
-		for _, lifetime := range lifetimes {
-			if lifetime >= timePoint {
-				survived++
-			}
-		}
-		survivalRate = append(survivalRate, float64(survived)/float64(len(lifetimes)))
-	}
+	halfLife, halfLifeCI, curve := survival.Estimate(observations)
 
-	// Find where survival rate crosses 0.5 to get half-life
-	var halfLife float64
-	for i, rate := range survivalRate {
-		if rate <= 0.5 {
-			timePoint := (maxAge * float64(i)) / float64(timePoints)
-			halfLife = timePoint
-			break
-		}
-	}
-
-	// If we never cross 0.5, use the median lifetime
-	if halfLife == 0 {
-		halfLife = lifetimes[len(lifetimes)/2]
-	}
-
-	stats := Stats{
+	stats = Stats{
 		HalfLife:       halfLife,
+		HalfLifeCI:     halfLifeCI,
 		TotalLines:     totalLines,
 		MedianAge:      lifetimes[len(lifetimes)/2],
-		SurvivalRate:   survivalRate,
-		OldestLine:     oldestLine.Content,
-		OldestLineAge:  oldestAge,
-		NewestLine:     newestLine.Content,
-		NewestLineAge:  newestAge,
+		SurvivalRate:   curve,
 		DeletedLines:   totalLines - survivingLines,
 		SurvivingLines: survivingLines,
-		FirstCommit:    commits[0].Author.When,
-		LastCommit:     commits[len(commits)-1].Author.When,
+		FirstCommit:    firstCommit,
+		LastCommit:     lastCommit,
+		Excluded:       excluded,
 	}
-
-	if !validateMode {
-		return stats, nil
+	if oldestLine != nil {
+		stats.OldestLine = oldestLine.Content
+		stats.OldestLineAge = oldestAge
 	}
+	if newestLine != nil {
+		stats.NewestLine = newestLine.Content
+		stats.NewestLineAge = newestAge
+	}
+	return stats, oldestLine, newestLine, true
+}
 
-	// For validation mode, collect interesting samples
-	samples := make(map[string]*CodeLine)
-	// Add oldest and newest lines
-	samples["oldest"] = oldestLine
-	samples["newest"] = newestLine
-
-	// Add some random surviving lines
-	survivingSlice := make([]*CodeLine, 0)
+// computeCohortStats slices codeLines along each of dims and computes an
+// independent Kaplan-Meier Stats for every resulting bucket. A line
+// contributes to one bucket per dimension, not one bucket for the
+// cross-product of all dimensions, so e.g. "dir:cmd" and "year:2021" are
+// both reported but "dir:cmd,year:2021" is not. Buckets smaller than
+// minSize are dropped as too noisy to report a half-life for.
+func computeCohortStats(codeLines map[LineKey]*CodeLine, dims []cohort.Dimension, minSize int, firstCommit, lastCommit time.Time) map[string]Stats {
+	groups := make(map[string][]*CodeLine)
 	for _, line := range codeLines {
-		if line.DeletedAt == nil {
-			survivingSlice = append(survivingSlice, line)
+		for _, dim := range dims {
+			var value string
+			switch dim {
+			case cohort.Author:
+				if line.AuthorEmail == "" {
+					continue
+				}
+				value = line.AuthorEmail
+			case cohort.Dir:
+				value = cohort.TopDir(line.File)
+			case cohort.Ext:
+				value = cohort.ExtOf(line.File)
+			case cohort.Year:
+				value = cohort.YearOf(line.CreatedAt.Year())
+			default:
+				continue
+			}
+			label := cohort.Label(dim, value)
+			groups[label] = append(groups[label], line)
 		}
 	}
-	if len(survivingSlice) > 5 {
-		for i := 0; i < 5; i++ {
-			idx := (i * len(survivingSlice)) / 5
-			samples[fmt.Sprintf("sample_%d", i)] = survivingSlice[idx]
+
+	cohortStats := make(map[string]Stats)
+	for label, lines := range groups {
+		if len(lines) < minSize {
+			continue
+		}
+		if stats, _, _, ok := computeStats(lines, firstCommit, lastCommit, filter.Stats{}); ok {
+			cohortStats[label] = stats
 		}
 	}
-
-	return ValidationReport{
-		Stats:    stats,
-		Samples:  samples,
-		Timeline: timeline,
-	}, nil
+	return cohortStats
 }
 
-func countDeletedLines(lines map[string]*CodeLine) int {
+func countDeletedLines(lines map[LineKey]*CodeLine) int {
 	count := 0
 	for _, line := range lines {
 		if line.DeletedAt != nil {
@@ -382,13 +1244,13 @@ func generateReport(result interface{}) string {
 }
 
 func generateStatsReport(stats Stats) string {
-	return fmt.Sprintf(`
+	report := fmt.Sprintf(`
 Code Half-Life Analysis Report
 ============================
 
 Summary Statistics:
 -----------------
-- Code Half-Life: %.1f days
+- Code Half-Life: %.1f days (95%% CI of survival there: %.1f-%.1f%%)
 - Median Age: %.1f days
 - Total Lines Analyzed: %d
 - Currently Surviving: %d (%.1f%%)
@@ -400,11 +1262,19 @@ Repository Timespan:
 - Last Commit: %s
 - Total Age: %.1f days
 
+Excluded Files:
+--------------
+- Vendored: %d files, %d lines
+- Generated: %d files, %d lines
+- Binary: %d files, %d lines
+
 Survival Rate:
 ------------
 %s
 `,
 		stats.HalfLife,
+		stats.HalfLifeCI[0]*100,
+		stats.HalfLifeCI[1]*100,
 		stats.MedianAge,
 		stats.TotalLines,
 		stats.SurvivingLines,
@@ -414,8 +1284,35 @@ Survival Rate:
 		stats.FirstCommit.Format("2006-01-02"),
 		stats.LastCommit.Format("2006-01-02"),
 		stats.LastCommit.Sub(stats.FirstCommit).Hours()/24,
+		stats.Excluded.VendoredFiles, stats.Excluded.VendoredLines,
+		stats.Excluded.GeneratedFiles, stats.Excluded.GeneratedLines,
+		stats.Excluded.BinaryFiles, stats.Excluded.BinaryLines,
 		formatSurvivalCurve(stats.SurvivalRate),
 	)
+
+	if len(stats.CohortStats) > 0 {
+		report += "\nCohort Breakdown:\n----------------\n" + formatCohortStats(stats.CohortStats)
+	}
+
+	return report
+}
+
+// formatCohortStats renders each cohort's half-life on its own line,
+// sorted by label so the output is stable across runs.
+func formatCohortStats(cohortStats map[string]Stats) string {
+	labels := make([]string, 0, len(cohortStats))
+	for label := range cohortStats {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var b strings.Builder
+	for _, label := range labels {
+		s := cohortStats[label]
+		b.WriteString(fmt.Sprintf("- %s: %.1f days half-life (95%% CI of survival there: %.1f-%.1f%%), %d lines\n",
+			label, s.HalfLife, s.HalfLifeCI[0]*100, s.HalfLifeCI[1]*100, s.TotalLines))
+	}
+	return b.String()
 }
 
 func generateValidationReport(report ValidationReport) string {
@@ -482,25 +1379,50 @@ func max(a, b int) int {
 	return b
 }
 
-func formatSurvivalCurve(rates []float64) string {
-	if len(rates) == 0 {
+func formatSurvivalCurve(curve []survival.Point) string {
+	if len(curve) == 0 {
 		return "No survival rate data available"
 	}
 
 	var result strings.Builder
 	numPoints := 5
-	step := len(rates) / numPoints
-	for i := 0; i < numPoints && i*step < len(rates); i++ {
-		result.WriteString(fmt.Sprintf("  %.0f%%: %.1f%%\n",
-			float64(i*step)/float64(len(rates))*100,
-			rates[i*step]*100))
+	step := len(curve) / numPoints
+	if step == 0 {
+		step = 1
+	}
+	for i := 0; i < len(curve); i += step {
+		p := curve[i]
+		result.WriteString(fmt.Sprintf("  t=%.1fd: %.1f%% (CI %.1f-%.1f%%)\n",
+			p.T, p.S*100, p.Lower*100, p.Upper*100))
 	}
 	return result.String()
 }
 
+// writeCommitGraph shells out to `git commit-graph write` so commitwalk can
+// use generation-number lookups instead of a full object-database walk.
+func writeCommitGraph(repoPath string) error {
+	cmd := exec.Command("git", "commit-graph", "write")
+	cmd.Dir = repoPath
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 func main() {
 	validateMode := flag.Bool("validate", false, "Enable validation mode with detailed output")
 	jsonOutput := flag.Bool("json", false, "Output results as JSON")
+	refreshCommitGraph := flag.Bool("refresh-commit-graph", false, "Run 'git commit-graph write' before analysis")
+	includeVendored := flag.Bool("include-vendored", false, "Include files marked linguist-vendored or matching built-in vendor paths")
+	includeGenerated := flag.Bool("include-generated", false, "Include files marked linguist-generated or matching built-in generated-file patterns")
+	includeBinary := flag.Bool("include-binary", false, "Include files detected as binary")
+	renameThreshold := flag.Float64("rename-threshold", 0.5, "Minimum whole-file content similarity to pair a deleted path with an added path as a rename")
+	modifyThreshold := flag.Float64("modify-threshold", 0.7, "Minimum line similarity to treat a deletion+addition pair as a modification rather than delete+create")
+	cohortFlag := flag.String("cohort", "", "Comma-separated cohort dimensions to break half-life down by: author,dir,ext,year")
+	minCohortSize := flag.Int("min-cohort-size", 20, "Minimum lines a cohort bucket must have to be reported")
+	cachePath := flag.String("cache", "", "Path to a cache file for incremental analysis; replays only commits added since the cache was written")
+	cacheVerify := flag.Bool("cache-verify", false, "With --cache, also run a full-history scan and log any drift from the incremental result")
 	flag.Parse()
 
 	args := flag.Args()
@@ -514,7 +1436,22 @@ func main() {
 		filePattern = args[1]
 	}
 
-	result, err := analyzeRepository(repoPath, filePattern, *validateMode)
+	if *refreshCommitGraph {
+		if err := writeCommitGraph(repoPath); err != nil {
+			log.Fatalf("Error refreshing commit-graph: %v", err)
+		}
+	}
+
+	filterOpts := filter.Options{
+		IncludeVendored:  *includeVendored,
+		IncludeGenerated: *includeGenerated,
+		IncludeBinary:    *includeBinary,
+	}
+
+	cohortDims := cohort.ParseDimensions(*cohortFlag)
+	cacheOpts := cacheOptions{path: *cachePath, verify: *cacheVerify}
+
+	result, err := analyzeRepository(repoPath, filePattern, *validateMode, filterOpts, *renameThreshold, *modifyThreshold, cohortDims, *minCohortSize, cacheOpts)
 	if err != nil {
 		log.Fatalf("Error analyzing repository: %v", err)
 	}
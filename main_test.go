@@ -0,0 +1,183 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestLevenshtein(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "abc", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+	}
+	for _, c := range cases {
+		if got := levenshtein(c.a, c.b); got != c.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestLineSimilarityIdenticalAfterWhitespaceNormalization(t *testing.T) {
+	if got := lineSimilarity("  foo(bar) ", "foo(bar)"); got != 1 {
+		t.Errorf("lineSimilarity of whitespace-only difference = %v, want 1", got)
+	}
+}
+
+func TestLineSimilarityBothEmpty(t *testing.T) {
+	if got := lineSimilarity("", ""); got != 1 {
+		t.Errorf("lineSimilarity(\"\", \"\") = %v, want 1", got)
+	}
+}
+
+func TestLineSimilarityCompletelyDifferent(t *testing.T) {
+	got := lineSimilarity("abc", "xyz")
+	if got != 0 {
+		t.Errorf("lineSimilarity(abc, xyz) = %v, want 0", got)
+	}
+}
+
+func TestShingleSimilarityIdentical(t *testing.T) {
+	if got := shingleSimilarity("hello world", "hello world", 3); got != 1 {
+		t.Errorf("shingleSimilarity of identical strings = %v, want 1", got)
+	}
+}
+
+func TestShingleSimilarityDisjoint(t *testing.T) {
+	got := shingleSimilarity("aaaa", "zzzz", 3)
+	if got != 0 {
+		t.Errorf("shingleSimilarity of disjoint strings = %v, want 0", got)
+	}
+}
+
+func TestShingleSimilarityEmptyInput(t *testing.T) {
+	if got := shingleSimilarity("", "anything", 3); got != 0 {
+		t.Errorf("shingleSimilarity with empty input = %v, want 0", got)
+	}
+}
+
+func TestShingleSimilarityShorterThanK(t *testing.T) {
+	// Below the shingle size, shingles() falls back to treating the whole
+	// string as one shingle, so two identical short strings still match.
+	got := shingleSimilarity("ab", "ab", 3)
+	if got != 1 {
+		t.Errorf("shingleSimilarity(ab, ab, 3) = %v, want 1", got)
+	}
+}
+
+// fakeChunk and fakeFilePatch are minimal diff.Chunk/diff.FilePatch
+// fixtures, just enough for advanceFileState and newFileState, which only
+// call Chunks(): real go-git patches would need a whole repository to
+// construct.
+type fakeChunk struct {
+	content string
+	typ     diff.Operation
+}
+
+func (c fakeChunk) Content() string      { return c.content }
+func (c fakeChunk) Type() diff.Operation { return c.typ }
+
+type fakeFilePatch struct{ chunks []diff.Chunk }
+
+func (p fakeFilePatch) IsBinary() bool             { return false }
+func (p fakeFilePatch) Files() (from, to diff.File) { return nil, nil }
+func (p fakeFilePatch) Chunks() []diff.Chunk        { return p.chunks }
+
+func TestAdvanceFileStateCarriesEqualLinesForward(t *testing.T) {
+	introHash := plumbing.NewHash("1111111111111111111111111111111111111111")
+	introDate := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	commitDate := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	commit := &object.Commit{
+		Hash:   plumbing.NewHash("2222222222222222222222222222222222222222"),
+		Author: object.Signature{When: commitDate},
+	}
+
+	// oldState as of the parent: three lines, all introduced by introHash.
+	oldState := []lineOrigin{
+		{hash: introHash, date: introDate},
+		{hash: introHash, date: introDate},
+		{hash: introHash, date: introDate},
+	}
+
+	// The patch carries the first and third lines through unchanged and
+	// replaces the middle one.
+	patch := fakeFilePatch{chunks: []diff.Chunk{
+		fakeChunk{content: "keep1\n", typ: diff.Equal},
+		fakeChunk{content: "replaced\n", typ: diff.Add},
+		fakeChunk{content: "old\n", typ: diff.Delete},
+		fakeChunk{content: "keep2\n", typ: diff.Equal},
+	}}
+
+	newState := advanceFileState(oldState, patch, commit, nil)
+
+	if len(newState) != 3 {
+		t.Fatalf("len(newState) = %d, want 3", len(newState))
+	}
+	if newState[0] != oldState[0] {
+		t.Errorf("newState[0] = %+v, want oldState[0] %+v (carried through Equal)", newState[0], oldState[0])
+	}
+	if newState[2] != oldState[2] {
+		t.Errorf("newState[2] = %+v, want oldState[2] %+v (carried through Equal)", newState[2], oldState[2])
+	}
+	if newState[1].hash != commit.Hash {
+		t.Errorf("newState[1].hash = %v, want %v (unmatched addition attributed to commit)", newState[1].hash, commit.Hash)
+	}
+}
+
+func TestAdvanceFileStateUsesMatchedOriginForPairedAddition(t *testing.T) {
+	introHash := plumbing.NewHash("1111111111111111111111111111111111111111")
+	introDate := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	commit := &object.Commit{
+		Hash:   plumbing.NewHash("2222222222222222222222222222222222222222"),
+		Author: object.Signature{When: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	patch := fakeFilePatch{chunks: []diff.Chunk{
+		fakeChunk{content: "reflowed\n", typ: diff.Add},
+		fakeChunk{content: "original\n", typ: diff.Delete},
+	}}
+
+	// applyLinePairing would report addIdx 0 ("reflowed") as a modify
+	// pairing that preserves the deleted line's original origin.
+	matchedAddOrigin := map[int]lineOrigin{0: {hash: introHash, date: introDate}}
+
+	newState := advanceFileState(nil, patch, commit, matchedAddOrigin)
+
+	if len(newState) != 1 {
+		t.Fatalf("len(newState) = %d, want 1", len(newState))
+	}
+	if newState[0].hash != introHash {
+		t.Errorf("newState[0].hash = %v, want %v (the matched deletion's true origin, not commit)", newState[0].hash, introHash)
+	}
+}
+
+func TestNewFileStateAttributesEveryLineToCommit(t *testing.T) {
+	commit := &object.Commit{
+		Hash:   plumbing.NewHash("3333333333333333333333333333333333333333"),
+		Author: object.Signature{When: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	patch := fakeFilePatch{chunks: []diff.Chunk{
+		fakeChunk{content: "one\ntwo\nthree\n", typ: diff.Add},
+	}}
+
+	state := newFileState(patch, commit)
+
+	if len(state) != 3 {
+		t.Fatalf("len(state) = %d, want 3", len(state))
+	}
+	for i, origin := range state {
+		if origin.hash != commit.Hash {
+			t.Errorf("state[%d].hash = %v, want %v", i, origin.hash, commit.Hash)
+		}
+	}
+}
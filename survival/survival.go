@@ -0,0 +1,136 @@
+// Package survival implements a Kaplan-Meier estimator for right-censored
+// durations, which is what a line's age actually is: a deleted line gives
+// an observed lifetime, but a surviving line only tells us it lasted at
+// least as long as it has been alive so far.
+package survival
+
+import "math"
+
+// Observation is a single duration sample. Event is true when the duration
+// ended in an observed death (deletion); false means the duration is
+// right-censored (the line is still alive as of the observation time).
+type Observation struct {
+	Duration float64
+	Event    bool
+}
+
+// Point is one step of the Kaplan-Meier survival curve at an observed event
+// time, with a 95% confidence band from Greenwood's formula.
+type Point struct {
+	T     float64
+	S     float64
+	Lower float64
+	Upper float64
+}
+
+// greenwoodZ is the z-score for a 95% confidence interval.
+const greenwoodZ = 1.96
+
+// Estimate computes the Kaplan-Meier survival curve for obs and returns the
+// curve alongside the half-life (the smallest t where S(t) <= 0.5, linearly
+// interpolated between the two straddling event times) and ci, the 95%
+// Greenwood confidence band on S (Lower, Upper) at the event time where
+// survival first drops to 0.5 or below.
+//
+// If survival never drops to 0.5, halfLife is returned as the largest
+// observed event time and ci is the interval at that final point. If obs
+// has no observed deaths at all, survival is undetermined rather than
+// instant: halfLife and ci are +Inf, not 0, since 0 would read as "dies
+// immediately" when the truth is the opposite.
+func Estimate(obs []Observation) (halfLife float64, ci [2]float64, curve []Point) {
+	times := eventTimes(obs)
+	if len(times) == 0 {
+		inf := math.Inf(1)
+		return inf, [2]float64{inf, inf}, nil
+	}
+
+	s := 1.0
+	varSum := 0.0 // running sum of d_i / (n_i * (n_i - d_i)) for Greenwood's formula
+
+	for _, t := range times {
+		d, n := deathsAndAtRisk(obs, t)
+		if n == 0 {
+			continue
+		}
+
+		prevS := s
+		if d > 0 {
+			s *= 1 - float64(d)/float64(n)
+		}
+		if n > d {
+			varSum += float64(d) / (float64(n) * float64(n-d))
+		}
+
+		variance := s * s * varSum
+		stderr := math.Sqrt(math.Max(variance, 0))
+		lower := math.Max(s-greenwoodZ*stderr, 0)
+		upper := math.Min(s+greenwoodZ*stderr, 1)
+		curve = append(curve, Point{T: t, S: s, Lower: lower, Upper: upper})
+
+		if halfLife == 0 && s <= 0.5 {
+			halfLife = interpolateHalfLife(prevTime(curve), prevS, t, s)
+			ci = [2]float64{lower, upper}
+		}
+	}
+
+	if halfLife == 0 && len(curve) > 0 {
+		last := curve[len(curve)-1]
+		halfLife = last.T
+		ci = [2]float64{last.Lower, last.Upper}
+	}
+
+	return halfLife, ci, curve
+}
+
+// prevTime returns the event time of the point before the last one in
+// curve (curve always has at least one point when called), or 0 if the
+// crossing happened at the very first event time.
+func prevTime(curve []Point) float64 {
+	if len(curve) < 2 {
+		return 0
+	}
+	return curve[len(curve)-2].T
+}
+
+// interpolateHalfLife linearly interpolates between (t0, s0) and (t1, s1)
+// to find the time at which survival crosses 0.5.
+func interpolateHalfLife(t0, s0, t1, s1 float64) float64 {
+	if s0 == s1 {
+		return t1
+	}
+	frac := (s0 - 0.5) / (s0 - s1)
+	return t0 + frac*(t1-t0)
+}
+
+// eventTimes returns the sorted, de-duplicated set of durations at which a
+// death (non-censored event) occurred.
+func eventTimes(obs []Observation) []float64 {
+	seen := make(map[float64]bool)
+	var times []float64
+	for _, o := range obs {
+		if o.Event && !seen[o.Duration] {
+			seen[o.Duration] = true
+			times = append(times, o.Duration)
+		}
+	}
+	for i := 1; i < len(times); i++ {
+		for j := i; j > 0 && times[j-1] > times[j]; j-- {
+			times[j-1], times[j] = times[j], times[j-1]
+		}
+	}
+	return times
+}
+
+// deathsAndAtRisk returns d (deaths observed exactly at t) and n (count of
+// observations still at risk just before t, i.e. duration >= t).
+func deathsAndAtRisk(obs []Observation, t float64) (d, n int) {
+	for _, o := range obs {
+		if o.Duration >= t {
+			n++
+		}
+		if o.Event && o.Duration == t {
+			d++
+		}
+	}
+	return d, n
+}
@@ -0,0 +1,92 @@
+package survival
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateAllCensored(t *testing.T) {
+	obs := []Observation{
+		{Duration: 10, Event: false},
+		{Duration: 20, Event: false},
+	}
+	// No deaths observed at all: survival is undetermined, not instant, so
+	// this must not read as a real (zero) half-life.
+	halfLife, ci, curve := Estimate(obs)
+	if !math.IsInf(halfLife, 1) {
+		t.Errorf("halfLife = %v, want +Inf (no observed deaths)", halfLife)
+	}
+	if !math.IsInf(ci[0], 1) || !math.IsInf(ci[1], 1) {
+		t.Errorf("ci = %v, want [+Inf, +Inf]", ci)
+	}
+	if curve != nil {
+		t.Errorf("curve = %v, want nil", curve)
+	}
+}
+
+func TestEstimateAllDeathsAtSameTime(t *testing.T) {
+	obs := []Observation{
+		{Duration: 5, Event: true},
+		{Duration: 5, Event: true},
+		{Duration: 5, Event: true},
+	}
+	// Survival drops straight from 1 to 0 at the only event time, with
+	// nothing before it to interpolate from, so halfLife is interpolated
+	// between (t=0, S=1) and (t=5, S=0): the midpoint, 2.5.
+	halfLife, ci, curve := Estimate(obs)
+	if halfLife != 2.5 {
+		t.Errorf("halfLife = %v, want 2.5", halfLife)
+	}
+	if len(curve) != 1 || curve[0].S != 0 {
+		t.Errorf("curve = %+v, want single point with S=0", curve)
+	}
+	if ci[0] != 0 || ci[1] != 0 {
+		t.Errorf("ci = %v, want [0, 0] (S already at 0)", ci)
+	}
+}
+
+func TestEstimateHalfLifeInterpolated(t *testing.T) {
+	// 4 lines at risk. One dies at t=10 (S drops 1.0 -> 0.75), the other
+	// three die at t=30 (S drops 0.75 -> 0). The 0.5 crossing falls
+	// strictly between those two event times, so it must be interpolated
+	// rather than landing exactly on either one.
+	obs := []Observation{
+		{Duration: 10, Event: true},
+		{Duration: 30, Event: true},
+		{Duration: 30, Event: true},
+		{Duration: 30, Event: true},
+	}
+	halfLife, _, curve := Estimate(obs)
+	if len(curve) != 2 {
+		t.Fatalf("curve = %+v, want 2 points", curve)
+	}
+	if halfLife <= 10 || halfLife >= 30 {
+		t.Errorf("halfLife = %v, want strictly between 10 and 30", halfLife)
+	}
+}
+
+func TestEstimateSurvivingLinesCensorButDontDie(t *testing.T) {
+	// A surviving line observed at t=100 keeps n (at-risk count) inflated
+	// for every event time up to 100, but since Event is false it never
+	// contributes a death itself.
+	obs := []Observation{
+		{Duration: 10, Event: true},
+		{Duration: 100, Event: false},
+	}
+	halfLife, _, curve := Estimate(obs)
+	if len(curve) != 1 {
+		t.Fatalf("curve = %+v, want 1 point (only one death)", curve)
+	}
+	// d=1, n=2 at t=10 -> S = 0.5, so halfLife should land exactly at the
+	// single event time rather than being pushed out to the censoring time.
+	if halfLife != 10 {
+		t.Errorf("halfLife = %v, want 10", halfLife)
+	}
+}
+
+func TestEstimateEmpty(t *testing.T) {
+	halfLife, _, curve := Estimate(nil)
+	if !math.IsInf(halfLife, 1) || curve != nil {
+		t.Errorf("Estimate(nil) = (%v, _, %v), want (+Inf, _, nil)", halfLife, curve)
+	}
+}